@@ -0,0 +1,77 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-yaml/yaml"
+)
+
+// authConfig is the token-based auth policy loaded from ConfigDir/auth.yml,
+// plus the websocket origins allowed to connect. An empty Token disables
+// auth entirely, matching gosuv's historical zero-config default.
+type authConfig struct {
+	Token   string   `yaml:"token"`
+	Origins []string `yaml:"origins"`
+}
+
+func loadAuthConfig(configDir string) (*authConfig, error) {
+	cfg := &authConfig{}
+	data, err := ioutil.ReadFile(filepath.Join(configDir, "auth.yml"))
+	if err == nil {
+		if err := yaml.Unmarshal(data, cfg); err != nil {
+			return nil, err
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	if tok := os.Getenv("GOSUV_TOKEN"); tok != "" {
+		cfg.Token = tok
+	}
+	return cfg, nil
+}
+
+// checkToken reports whether r carries the configured bearer token, via
+// either the Authorization header (REST) or a ?token= query param
+// (websockets, since browsers can't set custom headers on the upgrade
+// request).
+func (cfg *authConfig) checkToken(r *http.Request) bool {
+	if cfg.Token == "" {
+		return true
+	}
+	const prefix = "Bearer "
+	if h := r.Header.Get("Authorization"); strings.HasPrefix(h, prefix) {
+		return strings.TrimPrefix(h, prefix) == cfg.Token
+	}
+	return r.URL.Query().Get("token") == cfg.Token
+}
+
+// checkOrigin implements websocket.Upgrader.CheckOrigin. An empty Origins
+// list allows any origin, same as gorilla's own zero-value default;
+// otherwise the request's Origin header must exactly match an entry.
+func (cfg *authConfig) checkOrigin(r *http.Request) bool {
+	if len(cfg.Origins) == 0 {
+		return true
+	}
+	origin := r.Header.Get("Origin")
+	for _, o := range cfg.Origins {
+		if o == origin {
+			return true
+		}
+	}
+	return false
+}
+
+// requireAuth wraps h so it 401s requests that fail checkToken.
+func (s *Supervisor) requireAuth(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.auth.checkToken(r) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		h(w, r)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"io"
+	"os/exec"
+	"syscall"
+)
+
+// backend is the pluggable mechanism that actually runs a Program: either a
+// plain child process (execBackend) or a Docker container (dockerBackend).
+// Process drives a backend without knowing which kind it has.
+type backend interface {
+	// Start launches the process/container and returns its stdout and
+	// stderr streams for the caller to pump into the log pipeline.
+	Start() (stdout, stderr io.ReadCloser, err error)
+	// Stop asks the backend to shut down, honoring sig where supported.
+	Stop(sig syscall.Signal) error
+	// Wait blocks until the process/container has exited.
+	Wait() error
+}
+
+func newBackend(pg Program) backend {
+	switch pg.Type {
+	case "docker":
+		return newDockerBackend(pg)
+	default:
+		return newExecBackend(pg)
+	}
+}
+
+// execBackend runs a Program by shelling out, same as gosuv always has.
+type execBackend struct {
+	cmd *exec.Cmd
+}
+
+func newExecBackend(pg Program) *execBackend {
+	cmd := exec.Command("sh", "-c", pg.Command)
+	cmd.Dir = pg.Dir
+	return &execBackend{cmd: cmd}
+}
+
+func (b *execBackend) Start() (io.ReadCloser, io.ReadCloser, error) {
+	stdout, err := b.cmd.StdoutPipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	stderr, err := b.cmd.StderrPipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := b.cmd.Start(); err != nil {
+		return nil, nil, err
+	}
+	return stdout, stderr, nil
+}
+
+func (b *execBackend) Stop(sig syscall.Signal) error {
+	if b.cmd.Process == nil {
+		return nil
+	}
+	return b.cmd.Process.Signal(sig)
+}
+
+func (b *execBackend) Wait() error {
+	return b.cmd.Wait()
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	dockerSocket     = "/var/run/docker.sock"
+	dockerAPIVersion = "v1.41"
+)
+
+// dockerClient is a minimal Docker Engine API client talking to the local
+// daemon over its UNIX socket. It only implements the handful of endpoints
+// the docker backend needs, not a general purpose SDK.
+type dockerClient struct {
+	http *http.Client
+}
+
+func newDockerClient() *dockerClient {
+	return &dockerClient{
+		http: &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					return net.Dial("unix", dockerSocket)
+				},
+			},
+		},
+	}
+}
+
+func (c *dockerClient) url(path string) string {
+	return fmt.Sprintf("http://docker/%s%s", dockerAPIVersion, path)
+}
+
+func (c *dockerClient) do(method, path string, body interface{}) (*http.Response, error) {
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		r = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, c.url(path), r)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("docker %s %s: %s: %s", method, path, resp.Status, msg)
+	}
+	return resp, nil
+}
+
+type dockerCreateResponse struct {
+	ID string `json:"Id"`
+}
+
+// parsePortSpec parses a "host:container[/proto]" entry from Program.Ports
+// into the container port key Docker's API expects (e.g. "80/tcp") and the
+// host port to bind it to. proto defaults to tcp when omitted.
+func parsePortSpec(spec string) (containerPort, hostPort string, err error) {
+	parts := strings.SplitN(spec, ":", 2)
+	if len(parts) != 2 {
+		return "", "", fmt.Errorf("invalid port %q, want host:container[/proto]", spec)
+	}
+	hostPort, containerPort = parts[0], parts[1]
+	if !strings.Contains(containerPort, "/") {
+		containerPort += "/tcp"
+	}
+	return containerPort, hostPort, nil
+}
+
+func (c *dockerClient) createContainer(name string, pg Program) (string, error) {
+	exposedPorts := map[string]struct{}{}
+	portBindings := map[string][]map[string]string{}
+	for _, spec := range pg.Ports {
+		containerPort, hostPort, err := parsePortSpec(spec)
+		if err != nil {
+			return "", err
+		}
+		exposedPorts[containerPort] = struct{}{}
+		portBindings[containerPort] = append(portBindings[containerPort], map[string]string{"HostPort": hostPort})
+	}
+
+	body := map[string]interface{}{
+		"Image":        pg.Image,
+		"Env":          pg.Env,
+		"ExposedPorts": exposedPorts,
+		"HostConfig": map[string]interface{}{
+			"Binds":         pg.Volumes,
+			"NetworkMode":   pg.Network,
+			"RestartPolicy": map[string]interface{}{"Name": pg.RestartPolicy},
+			"PortBindings":  portBindings,
+		},
+	}
+	resp, err := c.do("POST", "/containers/create?name="+name, body)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	var out dockerCreateResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", err
+	}
+	return out.ID, nil
+}
+
+func (c *dockerClient) pullImage(image string) error {
+	resp, err := c.do("POST", "/images/create?fromImage="+image, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, err = io.Copy(io.Discard, resp.Body)
+	return err
+}
+
+func (c *dockerClient) startContainer(id string) error {
+	resp, err := c.do("POST", "/containers/"+id+"/start", nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (c *dockerClient) stopContainer(id string, sig syscall.Signal, timeout time.Duration) error {
+	path := fmt.Sprintf("/containers/%s/stop?signal=%d&t=%d", id, sig, int(timeout.Seconds()))
+	resp, err := c.do("POST", path, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+type dockerInspectResponse struct {
+	State struct {
+		Status string `json:"Status"` // created|running|exited|dead
+	} `json:"State"`
+}
+
+func (c *dockerClient) inspectContainer(id string) (*dockerInspectResponse, error) {
+	resp, err := c.do("GET", "/containers/"+id+"/json", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var out dockerInspectResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// logs streams the container's multiplexed stdout/stderr frames: an 8-byte
+// header (stream type byte + 3 unused + big-endian uint32 length) followed
+// by that many bytes of payload, per the non-TTY container logs endpoint.
+func (c *dockerClient) logs(id string) (io.ReadCloser, error) {
+	path := fmt.Sprintf("/containers/%s/logs?stdout=1&stderr=1&follow=1&tail=0", id)
+	resp, err := c.do("GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/binary"
+	"io"
+	"syscall"
+	"time"
+
+	"github.com/qiniu/log"
+)
+
+const dockerStopWait = 10 * time.Second
+
+// dockerBackend runs a Program as a container on the local Docker daemon
+// instead of shelling out, per the Program's Type: "docker" fields.
+type dockerBackend struct {
+	pg        Program
+	client    *dockerClient
+	container string
+}
+
+func newDockerBackend(pg Program) *dockerBackend {
+	return &dockerBackend{pg: pg, client: newDockerClient()}
+}
+
+func (b *dockerBackend) Start() (io.ReadCloser, io.ReadCloser, error) {
+	if b.pg.PullPolicy != "never" {
+		if err := b.client.pullImage(b.pg.Image); err != nil {
+			log.Printf("%s: pull %s: %v", b.pg.Name, b.pg.Image, err)
+		}
+	}
+	id, err := b.client.createContainer(b.pg.Name, b.pg)
+	if err != nil {
+		return nil, nil, err
+	}
+	b.container = id
+	if err := b.client.startContainer(id); err != nil {
+		return nil, nil, err
+	}
+
+	logStream, err := b.client.logs(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	outR, outW := io.Pipe()
+	errR, errW := io.Pipe()
+	go demuxDockerLog(logStream, outW, errW)
+	return outR, errR, nil
+}
+
+func (b *dockerBackend) Stop(sig syscall.Signal) error {
+	if b.container == "" {
+		return nil
+	}
+	return b.client.stopContainer(b.container, sig, dockerStopWait)
+}
+
+// Wait polls the container's state until it reaches exited or dead, mapping
+// into the same StateStopped/StateExited transition the exec backend reaches
+// via cmd.Wait().
+func (b *dockerBackend) Wait() error {
+	for {
+		info, err := b.client.inspectContainer(b.container)
+		if err != nil {
+			return err
+		}
+		switch info.State.Status {
+		case "exited", "dead":
+			return nil
+		}
+		time.Sleep(1 * time.Second)
+	}
+}
+
+// demuxDockerLog splits the Docker daemon's multiplexed log stream (see
+// dockerClient.logs) into separate stdout/stderr writers.
+func demuxDockerLog(r io.ReadCloser, stdout, stderr *io.PipeWriter) {
+	defer r.Close()
+	defer stdout.Close()
+	defer stderr.Close()
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			return
+		}
+		size := binary.BigEndian.Uint32(header[4:8])
+		payload := make([]byte, size)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			return
+		}
+		if header[0] == 2 {
+			stderr.Write(payload)
+		} else {
+			stdout.Write(payload)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import "sync"
+
+// FSMState is one of the states a Process can be in.
+type FSMState string
+
+const (
+	StateInit     FSMState = "init"
+	StateStarting FSMState = "starting"
+	StateRunning  FSMState = "running"
+	StateStopping FSMState = "stopping"
+	StateStopped  FSMState = "stopped"
+	StateExited   FSMState = "exited"
+	StateFatal    FSMState = "fatal"
+)
+
+// FSMEvent is an action requested on a Process.
+type FSMEvent string
+
+const (
+	StartEvent FSMEvent = "start"
+	StopEvent  FSMEvent = "stop"
+)
+
+// FSM guards concurrent reads/writes of a Process' current state.
+type FSM struct {
+	mu    sync.Mutex
+	state FSMState
+}
+
+func NewFSM(init FSMState) *FSM {
+	return &FSM{state: init}
+}
+
+func (f *FSM) Current() FSMState {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.state
+}
+
+func (f *FSM) SetState(s FSMState) {
+	f.mu.Lock()
+	f.state = s
+	f.mu.Unlock()
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"container/ring"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+const (
+	defaultRingSize   = 1000
+	defaultMaxLogSize = 10 << 20 // 10MB
+	defaultKeepCount  = 3
+)
+
+// ringLog keeps the last N lines of a stream in memory and fans new lines
+// out to subscribed websocket clients, mirroring the drop-the-slow-consumer
+// policy Supervisor.broadcastEvent already uses for eventCs.
+type ringLog struct {
+	mu    sync.Mutex
+	buf   *ring.Ring
+	subs  map[chan string]bool
+	total int64 // cumulative bytes of every line ever pushed, including its newline
+}
+
+func newRingLog(size int) *ringLog {
+	return &ringLog{
+		buf:  ring.New(size),
+		subs: make(map[chan string]bool),
+	}
+}
+
+func (r *ringLog) push(line string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.buf.Value = line
+	r.buf = r.buf.Next()
+	r.total += int64(len(line)) + 1
+	for c := range r.subs {
+		select {
+		case c <- line:
+		default:
+			delete(r.subs, c)
+			close(c)
+		}
+	}
+}
+
+// tail returns up to n most recent lines, oldest first.
+func (r *ringLog) tail(n int) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	lines := make([]string, 0, n)
+	r.buf.Do(func(v interface{}) {
+		if v != nil {
+			lines = append(lines, v.(string))
+		}
+	})
+	if n > 0 && len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines
+}
+
+// readFrom returns up to length bytes (0 meaning no limit) of the buffered
+// content starting at the given byte offset into the stream, the offset a
+// subsequent call should pass to resume from where this one left off, and
+// whether offset pointed at data older than the buffered window and could
+// not be honored exactly, mirroring supervisord's
+// read/tailProcessStdoutLog semantics. A negative offset counts back from
+// the current end of the stream.
+func (r *ringLog) readFrom(offset, length int64) (data string, newOffset int64, overflow bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var lines []string
+	r.buf.Do(func(v interface{}) {
+		if v != nil {
+			lines = append(lines, v.(string))
+		}
+	})
+	content := strings.Join(lines, "\n")
+	if len(lines) > 0 {
+		content += "\n"
+	}
+	bufStart := r.total - int64(len(content))
+	if bufStart < 0 {
+		bufStart = 0
+	}
+
+	if offset < 0 {
+		offset = r.total + offset
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	overflow = offset < bufStart
+	if offset < bufStart {
+		offset = bufStart
+	}
+
+	start := offset - bufStart
+	if start > int64(len(content)) {
+		start = int64(len(content))
+	}
+	end := int64(len(content))
+	if length > 0 && start+length < end {
+		end = start + length
+	}
+	return content[start:end], bufStart + end, overflow
+}
+
+func (r *ringLog) subscribe() chan string {
+	c := make(chan string, 64)
+	r.mu.Lock()
+	r.subs[c] = true
+	r.mu.Unlock()
+	return c
+}
+
+func (r *ringLog) unsubscribe(c chan string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.subs[c]; ok {
+		delete(r.subs, c)
+		close(c)
+	}
+}
+
+// rotateWriter is an io.Writer that logrotate-copytruncates the destination
+// file once it grows past maxSize, keeping up to keep rotated copies
+// (path.1, path.2, ...). copytruncate (rather than rename+reopen) is used
+// so the single long-lived *os.File handed to the child's pipe pump stays
+// valid across rotations.
+type rotateWriter struct {
+	mu      sync.Mutex
+	path    string
+	maxSize int64
+	keep    int
+	f       *os.File
+	size    int64
+}
+
+func newRotateWriter(path string, maxSize int64, keep int) (*rotateWriter, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &rotateWriter{path: path, maxSize: maxSize, keep: keep, f: f, size: fi.Size()}, nil
+}
+
+func (w *rotateWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	n, err := w.f.Write(p)
+	w.size += int64(n)
+	if err == nil && w.size >= w.maxSize {
+		w.rotate()
+	}
+	return n, err
+}
+
+func (w *rotateWriter) rotate() {
+	for i := w.keep - 1; i > 0; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", w.path, i), fmt.Sprintf("%s.%d", w.path, i+1))
+	}
+	if w.keep > 0 {
+		if data, err := os.ReadFile(w.path); err == nil {
+			os.WriteFile(fmt.Sprintf("%s.1", w.path), data, 0644)
+		}
+	}
+	w.f.Truncate(0)
+	w.f.Seek(0, 0)
+	w.size = 0
+}
+
+func (w *rotateWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.f.Close()
+}
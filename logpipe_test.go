@@ -0,0 +1,124 @@
+package main
+
+import "testing"
+
+func TestRingLogReadFrom(t *testing.T) {
+	// "one\ntwo\nthree\n" at indices 0-13, nothing yet evicted from the ring
+	// (size 10 comfortably holds all 3 lines).
+	fresh := func() *ringLog {
+		rl := newRingLog(10)
+		rl.push("one")
+		rl.push("two")
+		rl.push("three")
+		return rl
+	}
+
+	cases := []struct {
+		name         string
+		rl           *ringLog
+		offset       int64
+		length       int64
+		wantData     string
+		wantOffset   int64
+		wantOverflow bool
+	}{
+		{
+			name:       "full read from start",
+			rl:         fresh(),
+			offset:     0,
+			length:     0,
+			wantData:   "one\ntwo\nthree\n",
+			wantOffset: 14,
+		},
+		{
+			name:       "read from a middle offset",
+			rl:         fresh(),
+			offset:     4,
+			length:     0,
+			wantData:   "two\nthree\n",
+			wantOffset: 14,
+		},
+		{
+			name:       "length caps the returned chunk and offset advances by exactly that much",
+			rl:         fresh(),
+			offset:     0,
+			length:     4,
+			wantData:   "one\n",
+			wantOffset: 4,
+		},
+		{
+			name:       "negative offset counts back from the end",
+			rl:         fresh(),
+			offset:     -4,
+			length:     0,
+			wantData:   "ree\n",
+			wantOffset: 14,
+		},
+		{
+			name:       "offset past the end returns nothing",
+			rl:         fresh(),
+			offset:     100,
+			length:     0,
+			wantData:   "",
+			wantOffset: 14,
+		},
+		{
+			name:       "empty ring",
+			rl:         newRingLog(10),
+			offset:     0,
+			length:     0,
+			wantData:   "",
+			wantOffset: 0,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			data, newOffset, overflow := tc.rl.readFrom(tc.offset, tc.length)
+			if data != tc.wantData {
+				t.Errorf("data = %q, want %q", data, tc.wantData)
+			}
+			if newOffset != tc.wantOffset {
+				t.Errorf("newOffset = %d, want %d", newOffset, tc.wantOffset)
+			}
+			if overflow != tc.wantOverflow {
+				t.Errorf("overflow = %v, want %v", overflow, tc.wantOverflow)
+			}
+		})
+	}
+}
+
+// TestRingLogReadFromOverflow covers a ring that has wrapped and evicted
+// lines still referenced by an older client offset, as happens when a
+// supervisorctl tail client polls slower than the ring fills.
+func TestRingLogReadFromOverflow(t *testing.T) {
+	rl := newRingLog(2)
+	rl.push("a") // evicted
+	rl.push("b") // evicted
+	rl.push("c")
+	rl.push("d")
+	// total = 4 lines * ("x\n" = 2 bytes) = 8; buffered content is "c\nd\n"
+	// (4 bytes), so the buffered window starts at offset 4.
+
+	data, newOffset, overflow := rl.readFrom(0, 0)
+	if !overflow {
+		t.Fatalf("readFrom(0, 0) overflow = false, want true (offset 0 is behind the evicted window)")
+	}
+	if want := "c\nd\n"; data != want {
+		t.Errorf("data = %q, want %q", data, want)
+	}
+	if newOffset != 8 {
+		t.Errorf("newOffset = %d, want 8", newOffset)
+	}
+
+	data, newOffset, overflow = rl.readFrom(4, 0)
+	if overflow {
+		t.Errorf("readFrom(4, 0) overflow = true, want false (offset 4 is exactly the window start)")
+	}
+	if want := "c\nd\n"; data != want {
+		t.Errorf("data = %q, want %q", data, want)
+	}
+	if newOffset != 8 {
+		t.Errorf("newOffset = %d, want 8", newOffset)
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// startOrder returns program names ordered so each name appears after every
+// name in its DependsOn, breaking ties by ascending Priority (lower starts
+// first, then name for determinism). It errors on a DependsOn referencing
+// an unknown program or forming a cycle.
+func startOrder(pgs []Program) ([]string, error) {
+	byName := make(map[string]Program, len(pgs))
+	for _, pg := range pgs {
+		byName[pg.Name] = pg
+	}
+	for _, pg := range pgs {
+		for _, dep := range pg.DependsOn {
+			if _, ok := byName[dep]; !ok {
+				return nil, fmt.Errorf("%s depends on unknown program %q", pg.Name, dep)
+			}
+		}
+	}
+
+	const (
+		unvisited = 0
+		visiting  = 1
+		visited   = 2
+	)
+	state := make(map[string]int, len(pgs))
+	order := make([]string, 0, len(pgs))
+
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("dependency cycle detected at %s", name)
+		}
+		state[name] = visiting
+		deps := append([]string(nil), byName[name].DependsOn...)
+		sort.Slice(deps, func(i, j int) bool { return depLess(byName, deps[i], deps[j]) })
+		for _, dep := range deps {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+		order = append(order, name)
+		return nil
+	}
+
+	names := make([]string, 0, len(pgs))
+	for _, pg := range pgs {
+		names = append(names, pg.Name)
+	}
+	sort.Slice(names, func(i, j int) bool { return depLess(byName, names[i], names[j]) })
+	for _, name := range names {
+		if err := visit(name); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+func depLess(byName map[string]Program, a, b string) bool {
+	pa, pb := byName[a], byName[b]
+	if pa.Priority != pb.Priority {
+		return pa.Priority < pb.Priority
+	}
+	return a < b
+}
+
+// shutdownWaves groups program names into waves for parallel shutdown: every
+// name in a wave has had all of its dependents (the programs that name it
+// in their own DependsOn) already placed in an earlier wave, so a wave's
+// members can be stopped concurrently and only the next wave needs to wait
+// for them. It errors the same way startOrder does.
+func shutdownWaves(pgs []Program) ([][]string, error) {
+	if _, err := startOrder(pgs); err != nil {
+		return nil, err
+	}
+	byName := make(map[string]Program, len(pgs))
+	remaining := make(map[string]int, len(pgs)) // # of not-yet-waved dependents
+	for _, pg := range pgs {
+		byName[pg.Name] = pg
+	}
+	for _, pg := range pgs {
+		for _, dep := range pg.DependsOn {
+			remaining[dep]++
+		}
+	}
+
+	done := make(map[string]bool, len(pgs))
+	var waves [][]string
+	for len(done) < len(pgs) {
+		var wave []string
+		for _, pg := range pgs {
+			if !done[pg.Name] && remaining[pg.Name] == 0 {
+				wave = append(wave, pg.Name)
+			}
+		}
+		if len(wave) == 0 {
+			return nil, fmt.Errorf("dependency cycle detected while computing shutdown waves")
+		}
+		sort.Strings(wave)
+		for _, name := range wave {
+			done[name] = true
+		}
+		for _, name := range wave {
+			for _, dep := range byName[name].DependsOn {
+				remaining[dep]--
+			}
+		}
+		waves = append(waves, wave)
+	}
+	return waves, nil
+}
+
+// dependentsOf returns the set of program names that transitively depend on
+// name, directly or via another dependent. name itself is not included.
+func dependentsOf(pgs []Program, name string) map[string]bool {
+	children := map[string][]string{} // dependency name -> its dependents
+	for _, pg := range pgs {
+		for _, dep := range pg.DependsOn {
+			children[dep] = append(children[dep], pg.Name)
+		}
+	}
+	result := map[string]bool{}
+	var walk func(n string)
+	walk = func(n string) {
+		for _, child := range children[n] {
+			if !result[child] {
+				result[child] = true
+				walk(child)
+			}
+		}
+	}
+	walk(name)
+	return result
+}
@@ -0,0 +1,179 @@
+package main
+
+import "testing"
+
+func TestStartOrder(t *testing.T) {
+	cases := []struct {
+		name    string
+		pgs     []Program
+		wantErr bool
+	}{
+		{
+			name: "independent programs ordered by priority then name",
+			pgs: []Program{
+				{Name: "b", Priority: 1},
+				{Name: "a", Priority: 1},
+				{Name: "c", Priority: 0},
+			},
+		},
+		{
+			name: "dependency before dependent",
+			pgs: []Program{
+				{Name: "web", DependsOn: []string{"db"}},
+				{Name: "db"},
+			},
+		},
+		{
+			name: "unknown dependency errors",
+			pgs: []Program{
+				{Name: "web", DependsOn: []string{"db"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "self dependency is a cycle",
+			pgs: []Program{
+				{Name: "a", DependsOn: []string{"a"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "two-program cycle",
+			pgs: []Program{
+				{Name: "a", DependsOn: []string{"b"}},
+				{Name: "b", DependsOn: []string{"a"}},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			order, err := startOrder(tc.pgs)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("startOrder(%v) = %v, want error", tc.pgs, order)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("startOrder(%v) unexpected error: %v", tc.pgs, err)
+			}
+			if len(order) != len(tc.pgs) {
+				t.Fatalf("startOrder(%v) = %v, want %d names", tc.pgs, order, len(tc.pgs))
+			}
+			pos := make(map[string]int, len(order))
+			for i, name := range order {
+				pos[name] = i
+			}
+			for _, pg := range tc.pgs {
+				for _, dep := range pg.DependsOn {
+					if pos[dep] >= pos[pg.Name] {
+						t.Errorf("startOrder(%v) = %v, dependency %s must come before %s", tc.pgs, order, dep, pg.Name)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestShutdownWaves(t *testing.T) {
+	cases := []struct {
+		name    string
+		pgs     []Program
+		wantErr bool
+	}{
+		{
+			name: "independent programs share one wave",
+			pgs: []Program{
+				{Name: "a"},
+				{Name: "b"},
+				{Name: "c"},
+			},
+		},
+		{
+			name: "dependent stops before its dependency",
+			pgs: []Program{
+				{Name: "web", DependsOn: []string{"db"}},
+				{Name: "db"},
+			},
+		},
+		{
+			name: "diamond collapses dependents into one wave ahead of the shared dependency",
+			pgs: []Program{
+				{Name: "db"},
+				{Name: "web", DependsOn: []string{"db"}},
+				{Name: "worker", DependsOn: []string{"db"}},
+			},
+		},
+		{
+			name: "cycle errors",
+			pgs: []Program{
+				{Name: "a", DependsOn: []string{"b"}},
+				{Name: "b", DependsOn: []string{"a"}},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			waves, err := shutdownWaves(tc.pgs)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("shutdownWaves(%v) = %v, want error", tc.pgs, waves)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("shutdownWaves(%v) unexpected error: %v", tc.pgs, err)
+			}
+			wave := make(map[string]int, len(tc.pgs))
+			seen := 0
+			for i, w := range waves {
+				for _, name := range w {
+					wave[name] = i
+					seen++
+				}
+			}
+			if seen != len(tc.pgs) {
+				t.Fatalf("shutdownWaves(%v) = %v, covers %d names, want %d", tc.pgs, waves, seen, len(tc.pgs))
+			}
+			for _, pg := range tc.pgs {
+				for _, dep := range pg.DependsOn {
+					if wave[pg.Name] >= wave[dep] {
+						t.Errorf("shutdownWaves(%v) = %v, dependent %s must be in an earlier wave than dependency %s", tc.pgs, waves, pg.Name, dep)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestDependentsOf(t *testing.T) {
+	pgs := []Program{
+		{Name: "db"},
+		{Name: "web", DependsOn: []string{"db"}},
+		{Name: "worker", DependsOn: []string{"db"}},
+		{Name: "frontend", DependsOn: []string{"web"}},
+		{Name: "standalone"},
+	}
+
+	got := dependentsOf(pgs, "db")
+	want := map[string]bool{"web": true, "worker": true, "frontend": true}
+	if len(got) != len(want) {
+		t.Fatalf("dependentsOf(db) = %v, want %v", got, want)
+	}
+	for name := range want {
+		if !got[name] {
+			t.Errorf("dependentsOf(db) missing %s, got %v", name, got)
+		}
+	}
+	if got["db"] {
+		t.Errorf("dependentsOf(db) should not include db itself")
+	}
+
+	if got := dependentsOf(pgs, "standalone"); len(got) != 0 {
+		t.Errorf("dependentsOf(standalone) = %v, want empty", got)
+	}
+}
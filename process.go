@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/qiniu/log"
+)
+
+// Process drives a Program through its backend (exec or docker, see
+// backend.go) and its FSM state.
+type Process struct {
+	Name string `json:"name"`
+
+	*FSM        `json:"-"`
+	StateChange func(oldState, newState FSMState) `json:"-"`
+
+	program Program
+	backend backend
+	mu      sync.Mutex
+
+	logDir    string
+	stdoutLog *ringLog
+	stderrLog *ringLog
+}
+
+func NewProcess(pg Program) *Process {
+	return &Process{
+		Name:        pg.Name,
+		FSM:         NewFSM(StateInit),
+		StateChange: func(oldState, newState FSMState) {},
+		program:     pg,
+		stdoutLog:   newRingLog(defaultRingSize),
+		stderrLog:   newRingLog(defaultRingSize),
+	}
+}
+
+// attachLogs points the process at the directory its rotated stdout/stderr
+// log files live in. Must be called before the process is first started.
+func (p *Process) attachLogs(logDir string) {
+	p.logDir = logDir
+}
+
+func (p *Process) IsRunning() bool {
+	switch p.Current() {
+	case StateRunning, StateStarting:
+		return true
+	default:
+		return false
+	}
+}
+
+func (p *Process) setState(s FSMState) {
+	old := p.Current()
+	p.SetState(s)
+	p.StateChange(old, s)
+}
+
+func (p *Process) Operate(event FSMEvent) {
+	switch event {
+	case StartEvent:
+		p.start()
+	case StopEvent:
+		p.stopCommand()
+	}
+}
+
+func (p *Process) start() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.IsRunning() {
+		return
+	}
+	b := newBackend(p.program)
+	p.setState(StateStarting)
+	stdout, stderr, err := b.Start()
+	if err != nil {
+		log.Printf("%s: start failed: %v", p.Name, err)
+		p.setState(StateFatal)
+		return
+	}
+	p.backend = b
+	go p.pumpLog(stdout, "out", p.stdoutLog)
+	go p.pumpLog(stderr, "err", p.stderrLog)
+	p.setState(StateRunning)
+
+	go func() {
+		err := b.Wait()
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if err != nil {
+			log.Printf("%s: exited: %v", p.Name, err)
+		}
+		// Stopping means stopCommand/stopWithSignal already asked for this;
+		// anything else reaching here exited on its own while we thought it
+		// was starting or running, which supervisord calls EXITED rather
+		// than STOPPED.
+		if p.Current() == StateStopping {
+			p.setState(StateStopped)
+		} else {
+			p.setState(StateExited)
+		}
+	}()
+}
+
+// pumpLog tees one stream of the child's output to its rotating on-disk
+// log file and into the in-memory ring buffer used by wsLog.
+func (p *Process) pumpLog(r io.Reader, kind string, rl *ringLog) {
+	var w *rotateWriter
+	if p.logDir != "" {
+		path := filepath.Join(p.logDir, fmt.Sprintf("%s.%s.log", p.Name, kind))
+		var err error
+		w, err = newRotateWriter(path, defaultMaxLogSize, defaultKeepCount)
+		if err != nil {
+			log.Printf("%s: open log %s: %v", p.Name, path, err)
+		}
+	}
+	if w != nil {
+		defer w.Close()
+	}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if w != nil {
+			w.Write([]byte(line + "\n"))
+		}
+		rl.push(line)
+	}
+}
+
+func (p *Process) stopCommand() {
+	p.stopWithSignal(syscall.SIGTERM)
+}
+
+// stopWithSignal asks the backend to stop using the given signal. Unlike
+// stopCommand's implicit SIGTERM, callers doing graceful-shutdown escalation
+// use this to send SIGKILL once the grace period has elapsed.
+func (p *Process) stopWithSignal(sig syscall.Signal) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.backend == nil {
+		return nil
+	}
+	p.setState(StateStopping)
+	err := p.backend.Stop(sig)
+	if err != nil {
+		log.Printf("%s: stop: %v", p.Name, err)
+	}
+	return err
+}
+
+// waitUntilStopped polls the FSM state until it has left starting, running
+// and stopping (normally reaching StateStopped) or timeout elapses,
+// returning whether it stopped in time.
+func (p *Process) waitUntilStopped(timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		switch p.Current() {
+		case StateRunning, StateStarting, StateStopping:
+		default:
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+}
+
+// waitUntilRunning polls the FSM state until it reaches StateRunning or
+// timeout elapses, returning whether it came up in time. It gives up early
+// if the process reaches StateFatal.
+func (p *Process) waitUntilRunning(timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		switch p.Current() {
+		case StateRunning:
+			return true
+		case StateFatal:
+			return false
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+}
+
+// signalByName maps the Program.StopSignal config value to a syscall
+// signal, defaulting to SIGTERM for an empty or unrecognized name.
+func signalByName(name string) syscall.Signal {
+	switch name {
+	case "HUP":
+		return syscall.SIGHUP
+	case "INT":
+		return syscall.SIGINT
+	case "QUIT":
+		return syscall.SIGQUIT
+	case "USR1":
+		return syscall.SIGUSR1
+	case "USR2":
+		return syscall.SIGUSR2
+	case "TERM", "":
+		return syscall.SIGTERM
+	default:
+		return syscall.SIGTERM
+	}
+}
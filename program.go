@@ -0,0 +1,53 @@
+package main
+
+import "errors"
+
+// Program is the persisted, user-facing description of a managed process.
+type Program struct {
+	Name         string `json:"name" yaml:"name"`
+	Command      string `json:"command" yaml:"command"`
+	Dir          string `json:"dir" yaml:"dir"`
+	StartAuto    bool   `json:"start_auto" yaml:"start_auto"`
+	StartRetries int    `json:"start_retries" yaml:"start_retries"`
+
+	// Priority breaks ties between otherwise-independent programs when
+	// ordering start/stop; lower starts first. DependsOn names other
+	// programs that must reach RUNNING before this one starts.
+	Priority  int      `json:"priority,omitempty" yaml:"priority,omitempty"`
+	DependsOn []string `json:"depends_on,omitempty" yaml:"depends_on,omitempty"`
+
+	// StopSignal is one of HUP, INT, QUIT, TERM (default), USR1, USR2.
+	StopSignal string `json:"stop_signal,omitempty" yaml:"stop_signal,omitempty"`
+	// StopWaitSecs is how long to wait after StopSignal before escalating
+	// to SIGKILL. Defaults to 10 when zero.
+	StopWaitSecs int `json:"stop_wait_secs,omitempty" yaml:"stop_wait_secs,omitempty"`
+
+	// Type selects the backend that runs this program: "exec" (default) or
+	// "docker". The fields below only apply to Type == "docker".
+	Type          string   `json:"type,omitempty" yaml:"type,omitempty"`
+	Image         string   `json:"image,omitempty" yaml:"image,omitempty"`
+	Env           []string `json:"env,omitempty" yaml:"env,omitempty"`
+	Volumes       []string `json:"volumes,omitempty" yaml:"volumes,omitempty"`
+	Ports         []string `json:"ports,omitempty" yaml:"ports,omitempty"`
+	Network       string   `json:"network,omitempty" yaml:"network,omitempty"`
+	RestartPolicy string   `json:"restart_policy,omitempty" yaml:"restart_policy,omitempty"`
+	PullPolicy    string   `json:"pull_policy,omitempty" yaml:"pull_policy,omitempty"`
+}
+
+// Check validates the fields required to run the program.
+func (p *Program) Check() error {
+	if p.Name == "" {
+		return errors.New("Program name empty")
+	}
+	switch p.Type {
+	case "docker":
+		if p.Image == "" {
+			return errors.New("Program image empty")
+		}
+	default:
+		if p.Command == "" {
+			return errors.New("Program command empty")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"os"
+	"os/user"
+)
+
+// IsDir reports whether path exists and is a directory.
+func IsDir(path string) bool {
+	fi, err := os.Stat(path)
+	return err == nil && fi.IsDir()
+}
+
+// UserHomeDir returns the current user's home directory, falling back to
+// $HOME when the os/user lookup fails (e.g. cross-compiled, no cgo).
+func UserHomeDir() string {
+	if u, err := user.Current(); err == nil && u.HomeDir != "" {
+		return u.HomeDir
+	}
+	if home := os.Getenv("HOME"); home != "" {
+		return home
+	}
+	return "."
+}
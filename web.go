@@ -23,6 +23,7 @@ import (
 
 type Supervisor struct {
 	ConfigDir string
+	auth      *authConfig
 	pgs       []*Program
 	pgMap     map[string]*Program
 	procMap   map[string]*Process
@@ -36,6 +37,9 @@ func (s *Supervisor) programPath() string {
 
 func (s *Supervisor) newProcess(pg Program) *Process {
 	p := NewProcess(pg)
+	logDir := filepath.Join(s.ConfigDir, "logs")
+	os.MkdirAll(logDir, 0755)
+	p.attachLogs(logDir)
 	origFunc := p.StateChange
 	p.StateChange = func(oldState, newState FSMState) {
 		s.broadcastEvent(fmt.Sprintf("%s state: %s -> %s", p.Name, string(oldState), string(newState)))
@@ -63,32 +67,61 @@ func (s *Supervisor) addStatusChangeListener(c chan string) {
 	s.eventCs[c] = true
 }
 
+// removeStatusChangeListener deregisters c from eventCs and closes it, so
+// the goroutine pumping it into a websocket connection stops. Call this on
+// disconnect; without it, a dead peer leaks both the channel and that
+// goroutine forever.
+func (s *Supervisor) removeStatusChangeListener(c chan string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.eventCs[c]; ok {
+		delete(s.eventCs, c)
+		close(c)
+	}
+}
+
+// addOrUpdateProgram mutates s.pgs/pgMap/procMap under s.mu. An update
+// releases it before stopping the old process and creating the new one
+// (stopGracefully, newProcess's I/O), and saveDB runs unlocked, so a slow
+// reload or restart can't stall unrelated requests; adding a new program
+// creates its Process while still holding s.mu.
 func (s *Supervisor) addOrUpdateProgram(pg Program) error {
 	defer s.broadcastEvent(pg.Name + " add or update")
 
+	s.mu.Lock()
 	origPg, ok := s.pgMap[pg.Name]
 	if ok {
 		if !reflect.DeepEqual(origPg, &pg) {
 			log.Println("Update:", pg.Name)
+			s.pgMap[pg.Name] = &pg
+			for i, p := range s.pgs {
+				if p.Name == pg.Name {
+					s.pgs[i] = &pg
+					break
+				}
+			}
 			origProc := s.procMap[pg.Name]
 			isRunning := origProc.IsRunning()
+			s.mu.Unlock()
 			go func() {
-				origProc.Operate(StopEvent)
-
-				// TODO: wait state change
-				time.Sleep(2 * time.Second)
+				s.stopGracefully(*origPg, origProc)
 
 				newProc := s.newProcess(pg)
+				s.mu.Lock()
 				s.procMap[pg.Name] = newProc
+				s.mu.Unlock()
 				if isRunning {
 					newProc.Operate(StartEvent)
 				}
 			}()
+		} else {
+			s.mu.Unlock()
 		}
 	} else {
 		s.pgs = append(s.pgs, &pg)
 		s.pgMap[pg.Name] = &pg
 		s.procMap[pg.Name] = s.newProcess(pg)
+		s.mu.Unlock()
 		log.Println("Add:", pg.Name)
 	}
 	return s.saveDB()
@@ -97,6 +130,7 @@ func (s *Supervisor) addOrUpdateProgram(pg Program) error {
 // Check
 // - Yaml format
 // - Duplicated program
+// - DependsOn references a real program and contains no cycle
 func (s *Supervisor) readConfigFromDB() (pgs []Program, err error) {
 	data, err := ioutil.ReadFile(s.programPath())
 	if err != nil {
@@ -113,6 +147,9 @@ func (s *Supervisor) readConfigFromDB() (pgs []Program, err error) {
 		}
 		visited[pg.Name] = true
 	}
+	if _, err := startOrder(pgs); err != nil {
+		return nil, err
+	}
 	return
 }
 
@@ -121,6 +158,10 @@ func (s *Supervisor) loadDB() error {
 	if err != nil {
 		return err
 	}
+	order, err := startOrder(pgs)
+	if err != nil {
+		return err
+	}
 	// add or update program
 	visited := map[string]bool{}
 	for _, pg := range pgs {
@@ -128,12 +169,14 @@ func (s *Supervisor) loadDB() error {
 		s.addOrUpdateProgram(pg)
 	}
 	// delete not exists program
+	s.mu.Lock()
+	var toStop []*Process
 	for _, pg := range s.pgs {
 		if visited[pg.Name] {
 			continue
 		}
 		name := pg.Name
-		s.procMap[name].Operate(StopEvent)
+		toStop = append(toStop, s.procMap[name])
 		delete(s.procMap, name)
 		delete(s.pgMap, name)
 	}
@@ -142,16 +185,64 @@ func (s *Supervisor) loadDB() error {
 	for _, pg := range s.pgMap {
 		s.pgs = append(s.pgs, pg)
 	}
+	s.mu.Unlock()
+	for _, proc := range toStop {
+		proc.Operate(StopEvent)
+	}
+	s.autostart(order)
 	return nil
 }
 
+// autostart starts every StartAuto program in dependency order, waiting for
+// each of its DependsOn to reach RUNNING (or giving up after its own
+// StartRetries-derived timeout) before starting a dependent.
+func (s *Supervisor) autostart(order []string) {
+	for _, name := range order {
+		s.mu.Lock()
+		pg, ok := s.pgMap[name]
+		proc := s.procMap[name]
+		s.mu.Unlock()
+		if !ok || proc == nil || !pg.StartAuto {
+			continue
+		}
+		ready := true
+		for _, dep := range pg.DependsOn {
+			s.mu.Lock()
+			depProc := s.procMap[dep]
+			depPg := s.pgMap[dep]
+			s.mu.Unlock()
+			if depProc == nil {
+				continue
+			}
+			if !depProc.waitUntilRunning(startRetryWait(depPg)) {
+				log.Printf("%s: dependency %s never reached running, skipping autostart", name, dep)
+				ready = false
+				break
+			}
+		}
+		if ready {
+			proc.Operate(StartEvent)
+		}
+	}
+}
+
+func startRetryWait(pg *Program) time.Duration {
+	retries := pg.StartRetries
+	if retries <= 0 {
+		retries = 3
+	}
+	return time.Duration(retries) * time.Second
+}
+
 func (s *Supervisor) saveDB() error {
 	dir := filepath.Dir(s.programPath())
 	if !IsDir(dir) {
 		os.MkdirAll(dir, 0755)
 	}
 
+	s.mu.Lock()
 	data, err := yaml.Marshal(s.pgs)
+	s.mu.Unlock()
 	if err != nil {
 		return err
 	}
@@ -173,10 +264,12 @@ func (s *Supervisor) hSetting(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Supervisor) hGetProgram(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
 	procs := make([]*Process, 0, len(s.pgs))
 	for _, pg := range s.pgs {
 		procs = append(procs, s.procMap[pg.Name])
 	}
+	s.mu.Unlock()
 	data, err := json.Marshal(procs)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -210,7 +303,10 @@ func (s *Supervisor) hAddProgram(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 	var data []byte
-	if _, ok := s.pgMap[pg.Name]; ok {
+	s.mu.Lock()
+	_, exists := s.pgMap[pg.Name]
+	s.mu.Unlock()
+	if exists {
 		data, _ = json.Marshal(map[string]interface{}{
 			"status": 1,
 			"error":  fmt.Sprintf("Program %s already exists", strconv.Quote(pg.Name)),
@@ -232,7 +328,9 @@ func (s *Supervisor) hAddProgram(w http.ResponseWriter, r *http.Request) {
 
 func (s *Supervisor) hStartProgram(w http.ResponseWriter, r *http.Request) {
 	name := mux.Vars(r)["name"]
+	s.mu.Lock()
 	proc, ok := s.procMap[name]
+	s.mu.Unlock()
 	var data []byte
 	if !ok {
 		data, _ = json.Marshal(map[string]interface{}{
@@ -251,7 +349,9 @@ func (s *Supervisor) hStartProgram(w http.ResponseWriter, r *http.Request) {
 
 func (s *Supervisor) hStopProgram(w http.ResponseWriter, r *http.Request) {
 	name := mux.Vars(r)["name"]
+	s.mu.Lock()
 	proc, ok := s.procMap[name]
+	s.mu.Unlock()
 	var data []byte
 	if !ok {
 		data, _ = json.Marshal(map[string]interface{}{
@@ -268,26 +368,92 @@ func (s *Supervisor) hStopProgram(w http.ResponseWriter, r *http.Request) {
 	w.Write(data)
 }
 
+// hRestartProgram restarts name and everything that transitively depends on
+// it, stopping dependents-first and starting dependencies-first so the
+// dependency graph is never left running out of order.
+func (s *Supervisor) hRestartProgram(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	s.mu.Lock()
+	_, ok := s.procMap[name]
+	pgs := make([]Program, 0, len(s.pgs))
+	for _, pg := range s.pgs {
+		pgs = append(pgs, *pg)
+	}
+	s.mu.Unlock()
+	if !ok {
+		http.Error(w, fmt.Sprintf("Process %s not exists", strconv.Quote(name)), http.StatusNotFound)
+		return
+	}
+
+	order, err := startOrder(pgs)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	targets := dependentsOf(pgs, name)
+	targets[name] = true
+	restartOrder := make([]string, 0, len(targets))
+	for _, n := range order {
+		if targets[n] {
+			restartOrder = append(restartOrder, n)
+		}
+	}
+
+	go func() {
+		for i := len(restartOrder) - 1; i >= 0; i-- {
+			n := restartOrder[i]
+			s.mu.Lock()
+			proc, pg := s.procMap[n], s.pgMap[n]
+			s.mu.Unlock()
+			if proc != nil && pg != nil && proc.IsRunning() {
+				s.stopGracefully(*pg, proc)
+			}
+		}
+		for _, n := range restartOrder {
+			s.mu.Lock()
+			proc := s.procMap[n]
+			s.mu.Unlock()
+			if proc != nil {
+				proc.Operate(StartEvent)
+			}
+		}
+	}()
+
+	data, _ := json.Marshal(map[string]interface{}{
+		"status":  0,
+		"restart": restartOrder,
+	})
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
 var upgrader = websocket.Upgrader{}
 
 func (s *Supervisor) wsEvents(w http.ResponseWriter, r *http.Request) {
-	c, err := upgrader.Upgrade(w, r, nil)
+	if !s.auth.checkToken(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	raw, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Print("upgrade:", err)
 		return
 	}
+	c := wsConn{raw}
 	defer c.Close()
+	stop := s.wsWrap(raw)
+	defer stop()
 
 	ch := make(chan string, 0)
 	s.addStatusChangeListener(ch)
-	// s.eventCs[ch] = true
-	// s.eventCs = append(s.eventCs, ch)
+	defer s.removeStatusChangeListener(ch)
+
 	go func() {
 		for message := range ch {
-			// Question: type 1 ?
-			c.WriteMessage(1, []byte(message))
+			if err := c.WriteMessage(websocket.TextMessage, []byte(message)); err != nil {
+				return
+			}
 		}
-		close(ch)
 	}()
 	for {
 		mt, message, err := c.ReadMessage()
@@ -296,72 +462,251 @@ func (s *Supervisor) wsEvents(w http.ResponseWriter, r *http.Request) {
 			break
 		}
 		log.Printf("recv: %v %s", mt, message)
-		err = c.WriteMessage(mt, message)
-		if err != nil {
+		if err := c.WriteMessage(mt, message); err != nil {
 			log.Println("write:", err)
 			break
 		}
 	}
 }
 
+// wsLog streams a program's stdout/stderr over a websocket. It first flushes
+// the in-memory ring buffer so the client has recent context, then subscribes
+// to new lines as they arrive. Query params: stream=stdout|stderr|both
+// (default both), tail=N (default 1000).
 func (s *Supervisor) wsLog(w http.ResponseWriter, r *http.Request) {
+	if !s.auth.checkToken(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
 	name := mux.Vars(r)["name"]
-	log.Println(name)
+	s.mu.Lock()
+	proc, ok := s.procMap[name]
+	s.mu.Unlock()
+	if !ok {
+		http.Error(w, fmt.Sprintf("Process %s not exists", strconv.Quote(name)), http.StatusNotFound)
+		return
+	}
 
-	c, err := upgrader.Upgrade(w, r, nil)
+	stream := r.URL.Query().Get("stream")
+	if stream == "" {
+		stream = "both"
+	}
+	tailN := defaultRingSize
+	if n, err := strconv.Atoi(r.URL.Query().Get("tail")); err == nil && n > 0 {
+		tailN = n
+	}
+
+	var rls []*ringLog
+	if stream == "stdout" || stream == "both" {
+		rls = append(rls, proc.stdoutLog)
+	}
+	if stream == "stderr" || stream == "both" {
+		rls = append(rls, proc.stderrLog)
+	}
+	if len(rls) == 0 {
+		http.Error(w, fmt.Sprintf("invalid stream %s", strconv.Quote(stream)), http.StatusBadRequest)
+		return
+	}
+
+	raw, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Print("upgrade:", err)
 		return
 	}
+	c := wsConn{raw}
 	defer c.Close()
-	n := 0
+	stop := s.wsWrap(raw)
+	defer stop()
+
+	for _, rl := range rls {
+		for _, line := range rl.tail(tailN) {
+			c.WriteMessage(websocket.TextMessage, []byte(line))
+		}
+	}
+
+	merged := make(chan string, 256)
+	subs := make([]chan string, len(rls))
+	var wg sync.WaitGroup
+	for i, rl := range rls {
+		sub := rl.subscribe()
+		subs[i] = sub
+		wg.Add(1)
+		go func(sub chan string) {
+			defer wg.Done()
+			for line := range sub {
+				select {
+				case merged <- line:
+				case <-time.After(500 * time.Millisecond):
+				}
+			}
+		}(sub)
+	}
+	defer func() {
+		for i, rl := range rls {
+			rl.unsubscribe(subs[i])
+		}
+	}()
+	// unsubscribe closes each sub channel, which ends that forwarder's range
+	// loop; close merged only once every forwarder has exited so the reader
+	// goroutine below doesn't block on it forever.
+	go func() {
+		wg.Wait()
+		close(merged)
+	}()
+
+	go func() {
+		for line := range merged {
+			if err := c.WriteMessage(websocket.TextMessage, []byte(line)); err != nil {
+				return
+			}
+		}
+	}()
+
 	for {
-		n += 1
-		err := c.WriteMessage(1, []byte(strconv.Itoa(n)+" "+time.Now().Format(http.TimeFormat)+"Hello\n"))
-		if err != nil {
+		if _, _, err := c.ReadMessage(); err != nil {
 			break
 		}
-		time.Sleep(500 * time.Millisecond)
 	}
 }
 
+// stopGracefully sends pg.StopSignal (default SIGTERM) to proc and waits up
+// to pg.StopWaitSecs (default 10s) for it to reach StateStopped, escalating
+// to SIGKILL if the deadline passes with the process still running.
+func (s *Supervisor) stopGracefully(pg Program, proc *Process) {
+	wait := time.Duration(pg.StopWaitSecs) * time.Second
+	if wait <= 0 {
+		wait = 10 * time.Second
+	}
+	proc.stopWithSignal(signalByName(pg.StopSignal))
+	if proc.waitUntilStopped(wait) {
+		return
+	}
+	log.Printf("%s: did not stop within %s, sending SIGKILL", pg.Name, wait)
+	proc.stopWithSignal(syscall.SIGKILL)
+	proc.waitUntilStopped(5 * time.Second)
+}
+
+// shutdownWaves groups s.pgs into waves for parallel shutdown (see the
+// package-level shutdownWaves): every program in a wave can be stopped
+// concurrently with the rest of that wave, and only the next wave needs to
+// wait for it. The dependency graph was already validated at load time, so
+// a resolution failure here falls back to one wave holding every program
+// rather than blocking shutdown.
+func (s *Supervisor) shutdownWaves() [][]string {
+	s.mu.Lock()
+	pgs := make([]Program, 0, len(s.pgs))
+	for _, pg := range s.pgs {
+		pgs = append(pgs, *pg)
+	}
+	s.mu.Unlock()
+	waves, err := shutdownWaves(pgs)
+	if err != nil {
+		log.Printf("shutdownWaves: %v", err)
+		names := make([]string, 0, len(pgs))
+		for _, pg := range pgs {
+			names = append(names, pg.Name)
+		}
+		return [][]string{names}
+	}
+	return waves
+}
+
+// catchExitSignal handles graceful shutdown: the first SIGINT/SIGTERM stops
+// every running process wave by wave (programs within a wave in parallel,
+// honoring each Program's StopSignal and StopWaitSecs before escalating to
+// SIGKILL), waiting for every program of a wave to stop before starting the
+// next wave; a second signal received while that's in flight immediately escalates
+// everything to SIGKILL.
 func (s *Supervisor) catchExitSignal() {
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, syscall.SIGINT, syscall.SIGTERM)
 	go func() {
 		sig := <-c
-		fmt.Printf("Got signal: %v, stopping all running process\n", sig)
-		for _, proc := range s.procMap {
-			proc.stopCommand()
+		fmt.Printf("Got signal: %v, stopping all running processes\n", sig)
+
+		done := make(chan struct{})
+		go func() {
+			for _, wave := range s.shutdownWaves() {
+				var wg sync.WaitGroup
+				for _, name := range wave {
+					s.mu.Lock()
+					proc := s.procMap[name]
+					pg := s.pgMap[name]
+					s.mu.Unlock()
+					if proc == nil || pg == nil || !proc.IsRunning() {
+						continue
+					}
+					wg.Add(1)
+					go func(pg *Program, proc *Process) {
+						defer wg.Done()
+						s.stopGracefully(*pg, proc)
+					}(pg, proc)
+				}
+				wg.Wait()
+			}
+			close(done)
+		}()
+
+		select {
+		case <-done:
+		case <-c:
+			fmt.Println("Got second signal, killing all remaining processes")
+			s.mu.Lock()
+			procs := make([]*Process, 0, len(s.procMap))
+			for _, proc := range s.procMap {
+				procs = append(procs, proc)
+			}
+			s.mu.Unlock()
+			for _, proc := range procs {
+				if proc.IsRunning() || proc.Current() == StateStopping {
+					proc.stopWithSignal(syscall.SIGKILL)
+				}
+			}
 		}
+		os.Remove(s.pidPath())
 		fmt.Println("Finished. Exit with code 0")
 		os.Exit(0)
 	}()
 }
 
+func (s *Supervisor) pidPath() string {
+	return filepath.Join(s.ConfigDir, "supervisord.pid")
+}
+
 var defaultConfigDir = filepath.Join(UserHomeDir(), ".gosuv")
 
 func init() {
+	auth, err := loadAuthConfig(defaultConfigDir)
+	if err != nil {
+		log.Fatal(err)
+	}
 	suv := &Supervisor{
 		ConfigDir: defaultConfigDir,
+		auth:      auth,
 		pgMap:     make(map[string]*Program, 0),
 		procMap:   make(map[string]*Process, 0),
 		eventCs:   make(map[chan string]bool),
 	}
+	upgrader.CheckOrigin = suv.auth.checkOrigin
 	if err := suv.loadDB(); err != nil {
 		log.Fatal(err)
 	}
 	suv.catchExitSignal()
+	if err := ioutil.WriteFile(suv.pidPath(), []byte(strconv.Itoa(os.Getpid())), 0644); err != nil {
+		log.Printf("write pid file: %v", err)
+	}
 
 	r := mux.NewRouter()
 	r.HandleFunc("/", suv.hIndex)
 	r.HandleFunc("/settings/{name}", suv.hSetting)
 	r.HandleFunc("/api/programs", suv.hGetProgram).Methods("GET")
-	r.HandleFunc("/api/programs", suv.hAddProgram).Methods("POST")
-	r.HandleFunc("/api/programs/{name}/start", suv.hStartProgram).Methods("POST")
-	r.HandleFunc("/api/programs/{name}/stop", suv.hStopProgram).Methods("POST")
+	r.HandleFunc("/api/programs", suv.requireAuth(suv.hAddProgram)).Methods("POST")
+	r.HandleFunc("/api/programs/{name}/start", suv.requireAuth(suv.hStartProgram)).Methods("POST")
+	r.HandleFunc("/api/programs/{name}/stop", suv.requireAuth(suv.hStopProgram)).Methods("POST")
+	r.HandleFunc("/api/programs/{name}/restart", suv.requireAuth(suv.hRestartProgram)).Methods("POST")
 	r.HandleFunc("/ws/events", suv.wsEvents)
 	r.HandleFunc("/ws/logs/{name}", suv.wsLog)
+	r.HandleFunc("/RPC2", suv.requireAuth(suv.hXMLRPC)).Methods("POST")
 
 	fs := http.FileServer(http.Dir("res"))
 	http.Handle("/", r)
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	wsWriteWait  = 10 * time.Second
+	wsPongWait   = 60 * time.Second
+	wsPingPeriod = (wsPongWait * 9) / 10 // comfortably inside the pong deadline
+)
+
+// wsConn wraps *websocket.Conn so every WriteMessage call renews the write
+// deadline first, keeping a stalled peer from hanging a handler goroutine
+// forever.
+type wsConn struct {
+	*websocket.Conn
+}
+
+func (c wsConn) WriteMessage(mt int, data []byte) error {
+	c.SetWriteDeadline(time.Now().Add(wsWriteWait))
+	return c.Conn.WriteMessage(mt, data)
+}
+
+// wsWrap installs the read deadline and ping/pong keepalive shared by every
+// websocket handler: an initial read deadline, a pong handler that renews
+// it, and a ticker sending pings so a dead TCP peer is detected instead of
+// leaking the connection and whatever it's subscribed to. The returned stop
+// func must be deferred by the caller to tear the ticker down on disconnect.
+func (s *Supervisor) wsWrap(c *websocket.Conn) (stop func()) {
+	c.SetReadDeadline(time.Now().Add(wsPongWait))
+	c.SetPongHandler(func(string) error {
+		c.SetReadDeadline(time.Now().Add(wsPongWait))
+		return nil
+	})
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(wsPingPeriod)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				c.SetWriteDeadline(time.Now().Add(wsWriteWait))
+				if err := c.WriteMessage(websocket.PingMessage, nil); err != nil {
+					return
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() { close(done) }
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"syscall"
+)
+
+// supervisordStateCode maps our FSM states onto the supervisord protocol's
+// process state codes/names, so existing supervisorctl-family tooling sees
+// the states it expects. BACKOFF(30) has no equivalent here: gosuv never
+// auto-restarts a failed start attempt, so StateFatal is the only outcome of
+// a failed Start and is reported as FATAL rather than cycling through
+// BACKOFF first.
+func supervisordStateCode(s FSMState) (int, string) {
+	switch s {
+	case StateInit, StateStopped:
+		return 0, "STOPPED"
+	case StateStarting:
+		return 10, "STARTING"
+	case StateRunning:
+		return 20, "RUNNING"
+	case StateStopping:
+		return 40, "STOPPING"
+	case StateExited:
+		return 100, "EXITED"
+	case StateFatal:
+		return 200, "FATAL"
+	default:
+		return 1000, "UNKNOWN"
+	}
+}
+
+// hXMLRPC implements the subset of the supervisord XML-RPC protocol that
+// supervisorctl, Ansible's supervisorctl module, Nagios supervisord
+// plugins, and Python xmlrpclib clients need to operate against gosuv
+// unchanged.
+func (s *Supervisor) hXMLRPC(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var call rpcMethodCall
+	if err := xml.Unmarshal(body, &call); err != nil {
+		writeFault(w, 1, "parse error: "+err.Error())
+		return
+	}
+	params := make([]interface{}, len(call.Params))
+	for i, p := range call.Params {
+		params[i] = p.decode()
+	}
+
+	result, err := s.dispatchXMLRPC(call.MethodName, params)
+	if err != nil {
+		writeFault(w, 70, err.Error())
+		return
+	}
+	writeMethodResponse(w, result)
+}
+
+func (s *Supervisor) dispatchXMLRPC(method string, params []interface{}) (interface{}, error) {
+	switch method {
+	case "supervisor.getState":
+		return map[string]interface{}{"statecode": 1, "statename": "RUNNING"}, nil
+	case "supervisor.getAllProcessInfo":
+		s.mu.Lock()
+		names := make([]string, 0, len(s.pgs))
+		procs := make([]*Process, 0, len(s.pgs))
+		for _, pg := range s.pgs {
+			names = append(names, pg.Name)
+			procs = append(procs, s.procMap[pg.Name])
+		}
+		s.mu.Unlock()
+		out := make([]interface{}, 0, len(names))
+		for i, name := range names {
+			out = append(out, s.processInfo(name, procs[i]))
+		}
+		return out, nil
+	case "supervisor.getProcessInfo":
+		name, err := stringParam(params, 0)
+		if err != nil {
+			return nil, err
+		}
+		s.mu.Lock()
+		proc, ok := s.procMap[name]
+		s.mu.Unlock()
+		if !ok {
+			return nil, fmt.Errorf("BAD_NAME: %s", name)
+		}
+		return s.processInfo(name, proc), nil
+	case "supervisor.startProcess":
+		return s.xmlrpcOperate(params, StartEvent)
+	case "supervisor.stopProcess":
+		return s.xmlrpcOperate(params, StopEvent)
+	case "supervisor.startAllProcesses":
+		for _, proc := range s.allProcesses() {
+			proc.Operate(StartEvent)
+		}
+		return true, nil
+	case "supervisor.stopAllProcesses":
+		for _, proc := range s.allProcesses() {
+			proc.Operate(StopEvent)
+		}
+		return true, nil
+	case "supervisor.reloadConfig":
+		if err := s.loadDB(); err != nil {
+			return nil, err
+		}
+		// [[added], [changed], [dropped]], none of which gosuv tracks
+		// separately from the reload itself.
+		return []interface{}{[]interface{}{}, []interface{}{}, []interface{}{}}, nil
+	case "supervisor.addProcessGroup":
+		// gosuv has no process groups distinct from programs; the name
+		// must already exist in programs.yml.
+		name, err := stringParam(params, 0)
+		if err != nil {
+			return nil, err
+		}
+		s.mu.Lock()
+		_, ok := s.pgMap[name]
+		s.mu.Unlock()
+		if !ok {
+			return nil, fmt.Errorf("BAD_NAME: %s", name)
+		}
+		return true, nil
+	case "supervisor.removeProcessGroup":
+		return true, nil
+	case "supervisor.readProcessStdoutLog":
+		return s.xmlrpcReadLog(params)
+	case "supervisor.tailProcessStdoutLog":
+		return s.xmlrpcTailLog(params)
+	case "supervisor.shutdown":
+		// Route through the same SIGTERM path catchExitSignal already
+		// handles, so the PID file and running programs are cleaned up.
+		syscall.Kill(os.Getpid(), syscall.SIGTERM)
+		return true, nil
+	default:
+		return nil, fmt.Errorf("unknown method %s", method)
+	}
+}
+
+// allProcesses returns a snapshot of every known Process, for RPCs that
+// operate on all of them without racing a concurrent reloadConfig.
+func (s *Supervisor) allProcesses() []*Process {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	procs := make([]*Process, 0, len(s.procMap))
+	for _, proc := range s.procMap {
+		procs = append(procs, proc)
+	}
+	return procs
+}
+
+func stringParam(params []interface{}, i int) (string, error) {
+	if i >= len(params) {
+		return "", fmt.Errorf("missing param %d", i)
+	}
+	s, ok := params[i].(string)
+	if !ok {
+		return "", fmt.Errorf("param %d is not a string", i)
+	}
+	return s, nil
+}
+
+func (s *Supervisor) xmlrpcOperate(params []interface{}, event FSMEvent) (interface{}, error) {
+	name, err := stringParam(params, 0)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	proc, ok := s.procMap[name]
+	s.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("BAD_NAME: %s", name)
+	}
+	proc.Operate(event)
+	return true, nil
+}
+
+// intParam returns params[i] as an int, or def if the param is absent; it is
+// used for the optional offset/length arguments the log RPCs take.
+func intParam(params []interface{}, i int, def int) int {
+	if i >= len(params) {
+		return def
+	}
+	switch v := params[i].(type) {
+	case int:
+		return v
+	default:
+		return def
+	}
+}
+
+func (s *Supervisor) xmlrpcReadLog(params []interface{}) (string, error) {
+	name, err := stringParam(params, 0)
+	if err != nil {
+		return "", err
+	}
+	offset, length := intParam(params, 1, 0), intParam(params, 2, 0)
+	s.mu.Lock()
+	proc, ok := s.procMap[name]
+	s.mu.Unlock()
+	if !ok {
+		return "", fmt.Errorf("BAD_NAME: %s", name)
+	}
+	data, _, _ := proc.stdoutLog.readFrom(int64(offset), int64(length))
+	return data, nil
+}
+
+func (s *Supervisor) xmlrpcTailLog(params []interface{}) (interface{}, error) {
+	name, err := stringParam(params, 0)
+	if err != nil {
+		return nil, err
+	}
+	offset, length := intParam(params, 1, 0), intParam(params, 2, 0)
+	s.mu.Lock()
+	proc, ok := s.procMap[name]
+	s.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("BAD_NAME: %s", name)
+	}
+	data, newOffset, overflow := proc.stdoutLog.readFrom(int64(offset), int64(length))
+	return []interface{}{data, int(newOffset), overflow}, nil
+}
+
+// processInfo builds the supervisord processInfo struct for name/proc. proc
+// must come from a lookup the caller already did under s.mu, rather than
+// being re-derived here from name alone, so a program deleted by a
+// concurrent reloadConfig between the caller's check and this call can't
+// turn proc nil out from under us; a nil proc (deleted mid-call) reports
+// UNKNOWN instead of panicking.
+func (s *Supervisor) processInfo(name string, proc *Process) map[string]interface{} {
+	code, stateName := 1000, "UNKNOWN"
+	if proc != nil {
+		code, stateName = supervisordStateCode(proc.Current())
+	}
+	return map[string]interface{}{
+		"name":        name,
+		"group":       name,
+		"state":       code,
+		"statename":   stateName,
+		"description": stateName,
+		"pid":         0,
+	}
+}
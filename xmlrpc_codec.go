@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// rpcMethodCall is the subset of the XML-RPC request grammar gosuv needs to
+// parse: a method name plus a flat or nested list of params.
+type rpcMethodCall struct {
+	XMLName    xml.Name   `xml:"methodCall"`
+	MethodName string     `xml:"methodName"`
+	Params     []rpcValue `xml:"params>param>value"`
+}
+
+type rpcValue struct {
+	String  *string    `xml:"string"`
+	Int     *string    `xml:"int"`
+	I4      *string    `xml:"i4"`
+	Boolean *string    `xml:"boolean"`
+	Double  *string    `xml:"double"`
+	Array   *rpcArray  `xml:"array"`
+	Struct  *rpcStruct `xml:"struct"`
+	Chars   string     `xml:",chardata"` // bare string with no <string> wrapper
+}
+
+type rpcArray struct {
+	Values []rpcValue `xml:"data>value"`
+}
+
+type rpcStruct struct {
+	Members []rpcMember `xml:"member"`
+}
+
+type rpcMember struct {
+	Name  string   `xml:"name"`
+	Value rpcValue `xml:"value"`
+}
+
+// decode converts a parsed <value> into a plain Go value: string, int,
+// bool, float64, []interface{}, or map[string]interface{}.
+func (v rpcValue) decode() interface{} {
+	switch {
+	case v.String != nil:
+		return *v.String
+	case v.Int != nil:
+		n, _ := strconv.Atoi(*v.Int)
+		return n
+	case v.I4 != nil:
+		n, _ := strconv.Atoi(*v.I4)
+		return n
+	case v.Boolean != nil:
+		return *v.Boolean == "1"
+	case v.Double != nil:
+		f, _ := strconv.ParseFloat(*v.Double, 64)
+		return f
+	case v.Array != nil:
+		out := make([]interface{}, len(v.Array.Values))
+		for i, e := range v.Array.Values {
+			out[i] = e.decode()
+		}
+		return out
+	case v.Struct != nil:
+		out := make(map[string]interface{}, len(v.Struct.Members))
+		for _, m := range v.Struct.Members {
+			out[m.Name] = m.Value.decode()
+		}
+		return out
+	default:
+		return v.Chars
+	}
+}
+
+func encodeValue(w *bytes.Buffer, v interface{}) {
+	w.WriteString("<value>")
+	switch t := v.(type) {
+	case string:
+		fmt.Fprintf(w, "<string>%s</string>", xmlEscape(t))
+	case int:
+		fmt.Fprintf(w, "<int>%d</int>", t)
+	case bool:
+		b := 0
+		if t {
+			b = 1
+		}
+		fmt.Fprintf(w, "<boolean>%d</boolean>", b)
+	case float64:
+		fmt.Fprintf(w, "<double>%v</double>", t)
+	case []interface{}:
+		w.WriteString("<array><data>")
+		for _, e := range t {
+			encodeValue(w, e)
+		}
+		w.WriteString("</data></array>")
+	case map[string]interface{}:
+		w.WriteString("<struct>")
+		for k, e := range t {
+			fmt.Fprintf(w, "<member><name>%s</name>", xmlEscape(k))
+			encodeValue(w, e)
+			w.WriteString("</member>")
+		}
+		w.WriteString("</struct>")
+	case nil:
+		w.WriteString("<string></string>")
+	default:
+		fmt.Fprintf(w, "<string>%v</string>", t)
+	}
+	w.WriteString("</value>")
+}
+
+func xmlEscape(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+func writeMethodResponse(w io.Writer, result interface{}) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	buf.WriteString("<methodResponse><params><param>")
+	encodeValue(&buf, result)
+	buf.WriteString("</param></params></methodResponse>")
+	w.Write(buf.Bytes())
+}
+
+func writeFault(w io.Writer, code int, message string) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	buf.WriteString("<methodResponse><fault>")
+	encodeValue(&buf, map[string]interface{}{"faultCode": code, "faultString": message})
+	buf.WriteString("</fault></methodResponse>")
+	w.Write(buf.Bytes())
+}